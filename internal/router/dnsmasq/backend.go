@@ -0,0 +1,45 @@
+package dnsmasq
+
+import "github.com/Control-D-Inc/ctrld"
+
+// Backend represents a router firmware capable of running dnsmasq (or a
+// dnsmasq-compatible resolver) fronted by ctrld. It lets the rest of ctrld
+// discover the running platform once, then ask for a rendered config and
+// install path without sprinkling router-specific switches throughout the
+// codebase.
+type Backend interface {
+	// Name returns the backend's identifying name, e.g. "merlin", "openwrt".
+	Name() string
+	// ConfigPaths returns the dnsmasq (or equivalent) config file paths this backend manages.
+	ConfigPaths() ([]string, error)
+	// Render generates the config content to install for cfg.
+	Render(cfg *ctrld.Config) (string, error)
+	// Install renders cfg and puts it into place, performing any
+	// backend-specific activation (e.g. Merlin's postconf hook, UCI commit).
+	Install(cfg *ctrld.Config) error
+	// Uninstall removes anything Install put in place, restoring prior config.
+	Uninstall() error
+	// Reload asks the backend's dnsmasq instance to re-read its config.
+	Reload() error
+}
+
+// Backends lists all known Backend implementations, in detection priority order.
+var Backends = []Backend{
+	&MerlinBackend{},
+	&FirewallaBackend{},
+	&OpenWrtBackend{},
+	&DDWrtBackend{},
+	&EdgeOSBackend{},
+	&PfSenseBackend{},
+}
+
+// DetectBackend returns the Backend matching the router firmware ctrld is
+// currently running on, or nil if none of the known backends apply.
+func DetectBackend() Backend {
+	for _, b := range Backends {
+		if paths, err := b.ConfigPaths(); err == nil && len(paths) > 0 {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package dnsmasq
+
+import (
+	"os/exec"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// ddWrtDnsmasqOptionsVar is the nvram variable DD-WRT appends to dnsmasq's
+// generated config at boot/service-restart time.
+const ddWrtDnsmasqOptionsVar = "dnsmasq_options"
+
+// DDWrtBackend targets DD-WRT firmware, which has no on-disk dnsmasq.conf:
+// extra directives are stored in the dnsmasq_options nvram variable instead.
+type DDWrtBackend struct{}
+
+func (b *DDWrtBackend) Name() string { return "ddwrt" }
+
+// ConfigPaths reports the nvram variable this backend manages, so callers
+// parsing ConfigPaths as real files should special-case the "nvram:" scheme.
+func (b *DDWrtBackend) ConfigPaths() ([]string, error) {
+	if _, err := exec.LookPath("nvram"); err != nil {
+		return nil, err
+	}
+	return []string{"nvram:" + ddWrtDnsmasqOptionsVar}, nil
+}
+
+// Render generates the dnsmasq config content for cfg.
+func (b *DDWrtBackend) Render(cfg *ctrld.Config) (string, error) {
+	return ConfTmpl(ConfigContentTmpl, cfg)
+}
+
+// Install stores the rendered config in nvram and persists it across reboots.
+func (b *DDWrtBackend) Install(cfg *ctrld.Config) error {
+	content, err := b.Render(cfg)
+	if err != nil {
+		return err
+	}
+	if err := exec.Command("nvram", "set", ddWrtDnsmasqOptionsVar+"="+content).Run(); err != nil {
+		return err
+	}
+	return exec.Command("nvram", "commit").Run()
+}
+
+// Uninstall clears the nvram variable ctrld owns.
+func (b *DDWrtBackend) Uninstall() error {
+	if err := exec.Command("nvram", "set", ddWrtDnsmasqOptionsVar+"=").Run(); err != nil {
+		return err
+	}
+	return exec.Command("nvram", "commit").Run()
+}
+
+// Reload restarts the dnsmasq service so it regenerates its config from nvram.
+func (b *DDWrtBackend) Reload() error {
+	return exec.Command("stopservice", "dnsmasq", "-r").Run()
+}
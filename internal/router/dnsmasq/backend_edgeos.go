@@ -0,0 +1,59 @@
+package dnsmasq
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// edgeOSConfDir is read by dnsmasq on EdgeOS/UBIOS but, unlike
+// /etc/dnsmasq.conf, is never rewritten by the vyatta config mangler, so
+// ctrld's drop-in survives router config changes.
+const edgeOSConfDir = "/etc/dnsmasq.d"
+
+// edgeOSConfPath is ctrld's drop-in inside edgeOSConfDir.
+const edgeOSConfPath = edgeOSConfDir + "/ctrld.conf"
+
+// EdgeOSBackend targets Ubiquiti EdgeOS/UBIOS routers.
+type EdgeOSBackend struct{}
+
+func (b *EdgeOSBackend) Name() string { return "edgeos" }
+
+// ConfigPaths returns edgeOSConfPath if edgeOSConfDir exists, i.e. this looks
+// like an EdgeOS/UBIOS router.
+func (b *EdgeOSBackend) ConfigPaths() ([]string, error) {
+	if _, err := os.Stat(edgeOSConfDir); err != nil {
+		return nil, err
+	}
+	return []string{edgeOSConfPath}, nil
+}
+
+// Render generates the dnsmasq config content for cfg, leaving dnsmasq's cache
+// enabled since EdgeOS/UBIOS regenerate their own dnsmasq config.
+func (b *EdgeOSBackend) Render(cfg *ctrld.Config) (string, error) {
+	return ConfTmplWithCacheDisabled(ConfigContentTmpl, cfg, false)
+}
+
+// Install drops ctrld's config into edgeOSConfDir, leaving the
+// vyatta-managed /etc/dnsmasq.conf untouched.
+func (b *EdgeOSBackend) Install(cfg *ctrld.Config) error {
+	content, err := b.Render(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(edgeOSConfPath, []byte(content), 0o644)
+}
+
+// Uninstall removes ctrld's drop-in config.
+func (b *EdgeOSBackend) Uninstall() error {
+	if err := os.Remove(edgeOSConfPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Reload restarts the dnsmasq service so it re-reads edgeOSConfDir.
+func (b *EdgeOSBackend) Reload() error {
+	return exec.Command("/etc/init.d/dnsmasq", "restart").Run()
+}
@@ -0,0 +1,53 @@
+package dnsmasq
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// firewallaLocalConfPath is the per-box dnsmasq include ctrld installs on Firewalla.
+const firewallaLocalConfPath = "/home/pi/.firewalla/config/dnsmasq_local/ctrld.conf"
+
+// FirewallaBackend targets Firewalla boxes, which run dnsmasq per network
+// interface and pick up extra config dropped under a local conf directory.
+type FirewallaBackend struct{}
+
+func (b *FirewallaBackend) Name() string { return "firewalla" }
+
+// ConfigPaths returns the per-interface dnsmasq config files Firewalla generates.
+func (b *FirewallaBackend) ConfigPaths() ([]string, error) {
+	return firewallaDnsmasqConfFiles()
+}
+
+// Render generates the dnsmasq config content for cfg.
+func (b *FirewallaBackend) Render(cfg *ctrld.Config) (string, error) {
+	return FirewallaConfTmpl(ConfigContentTmpl, cfg)
+}
+
+// Install writes ctrld's config into Firewalla's dnsmasq local conf directory.
+func (b *FirewallaBackend) Install(cfg *ctrld.Config) error {
+	content, err := b.Render(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(firewallaLocalConfPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(firewallaLocalConfPath, []byte(content), 0o644)
+}
+
+// Uninstall removes ctrld's local conf drop-in.
+func (b *FirewallaBackend) Uninstall() error {
+	if err := os.Remove(firewallaLocalConfPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Reload signals all running dnsmasq instances to re-read their config.
+func (b *FirewallaBackend) Reload() error {
+	return exec.Command("pkill", "-HUP", "dnsmasq").Run()
+}
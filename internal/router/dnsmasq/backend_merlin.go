@@ -0,0 +1,61 @@
+package dnsmasq
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// MerlinBackend targets Asuswrt-Merlin firmware, which reloads dnsmasq
+// config through a postconf script hook rather than a plain config file.
+type MerlinBackend struct{}
+
+func (b *MerlinBackend) Name() string { return "merlin" }
+
+// ConfigPaths returns MerlinPostConfPath if this looks like a Merlin router.
+func (b *MerlinBackend) ConfigPaths() ([]string, error) {
+	if _, err := os.Stat("/usr/sbin/helper.sh"); err != nil {
+		return nil, err
+	}
+	return []string{MerlinPostConfPath}, nil
+}
+
+// Render generates the postconf script content for cfg.
+func (b *MerlinBackend) Render(cfg *ctrld.Config) (string, error) {
+	return ConfTmpl(MerlinPostConfTmpl, cfg)
+}
+
+// Install writes the postconf script and marks it executable, as Merlin
+// only invokes dnsmasq.postconf hooks that have the exec bit set.
+func (b *MerlinBackend) Install(cfg *ctrld.Config) error {
+	content, err := b.Render(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(MerlinPostConfPath, []byte(content), 0o755); err != nil {
+		return err
+	}
+	return os.Chmod(MerlinPostConfPath, 0o755)
+}
+
+// Uninstall removes the postconf script, but only if it's the one ctrld installed.
+func (b *MerlinBackend) Uninstall() error {
+	data, err := os.ReadFile(MerlinPostConfPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !strings.Contains(string(data), CtrldMarker) {
+		return nil
+	}
+	return os.Remove(MerlinPostConfPath)
+}
+
+// Reload restarts dnsmasq so it picks up the new postconf script.
+func (b *MerlinBackend) Reload() error {
+	return exec.Command("service", "restart_dnsmasq").Run()
+}
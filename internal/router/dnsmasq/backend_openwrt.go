@@ -0,0 +1,69 @@
+package dnsmasq
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// openWrtConfDir is the dnsmasq.d directory OpenWrt's dnsmasq instance is
+// configured (via the dhcp UCI config) to read extra config from.
+const openWrtConfDir = "/tmp/dnsmasq.d"
+
+// openWrtConfPath is ctrld's drop-in inside openWrtConfDir.
+const openWrtConfPath = openWrtConfDir + "/ctrld.conf"
+
+// OpenWrtBackend targets OpenWrt firmware, which configures dnsmasq through
+// UCI (/etc/config/dhcp) and reads extra directives from a confdir.
+type OpenWrtBackend struct{}
+
+func (b *OpenWrtBackend) Name() string { return "openwrt" }
+
+// ConfigPaths returns /etc/config/dhcp and openWrtConfPath if
+// /etc/config/dhcp exists, i.e. this looks like an OpenWrt router.
+func (b *OpenWrtBackend) ConfigPaths() ([]string, error) {
+	const dhcpConfig = "/etc/config/dhcp"
+	if _, err := os.Stat(dhcpConfig); err != nil {
+		return nil, err
+	}
+	return []string{dhcpConfig, openWrtConfPath}, nil
+}
+
+// Render generates the dnsmasq config content for cfg.
+func (b *OpenWrtBackend) Render(cfg *ctrld.Config) (string, error) {
+	return ConfTmpl(ConfigContentTmpl, cfg)
+}
+
+// Install points dnsmasq's UCI confdir at openWrtConfDir and drops ctrld's
+// config in it, so the rest of /etc/config/dhcp is left untouched.
+func (b *OpenWrtBackend) Install(cfg *ctrld.Config) error {
+	content, err := b.Render(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(openWrtConfDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(openWrtConfPath, []byte(content), 0o644); err != nil {
+		return err
+	}
+	if err := exec.Command("uci", "set", "dhcp.@dnsmasq[0].confdir="+openWrtConfDir).Run(); err != nil {
+		return err
+	}
+	return exec.Command("uci", "commit", "dhcp").Run()
+}
+
+// Uninstall removes ctrld's drop-in config. The dnsmasq confdir setting that
+// Install made in /etc/config/dhcp is left in place.
+func (b *OpenWrtBackend) Uninstall() error {
+	if err := os.Remove(openWrtConfPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Reload restarts the dnsmasq init script so it re-reads UCI and the confdir.
+func (b *OpenWrtBackend) Reload() error {
+	return exec.Command("/etc/init.d/dnsmasq", "reload").Run()
+}
@@ -0,0 +1,60 @@
+package dnsmasq
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// pfSenseConfDir is where pfSense/OPNsense's DNS Forwarder (dnsmasq) package
+// reads extra config from, analogous to dnsmasq.d on Linux routers.
+const pfSenseConfDir = "/var/etc/dnsmasq.conf.d"
+
+// pfSenseConfPath is ctrld's drop-in inside pfSenseConfDir.
+const pfSenseConfPath = pfSenseConfDir + "/ctrld.conf"
+
+// PfSenseBackend targets pfSense/OPNsense routers running the dnsmasq-based
+// DNS Forwarder package (as opposed to the default Unbound resolver).
+type PfSenseBackend struct{}
+
+func (b *PfSenseBackend) Name() string { return "pfsense" }
+
+// ConfigPaths returns pfSenseConfPath if pfSenseConfDir exists, i.e. this
+// looks like a pfSense/OPNsense router.
+func (b *PfSenseBackend) ConfigPaths() ([]string, error) {
+	if _, err := os.Stat(pfSenseConfDir); err != nil {
+		return nil, err
+	}
+	return []string{pfSenseConfPath}, nil
+}
+
+// Render generates the dnsmasq config content for cfg.
+func (b *PfSenseBackend) Render(cfg *ctrld.Config) (string, error) {
+	return ConfTmpl(ConfigContentTmpl, cfg)
+}
+
+// Install drops ctrld's config into pfSenseConfDir.
+func (b *PfSenseBackend) Install(cfg *ctrld.Config) error {
+	content, err := b.Render(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(pfSenseConfDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(pfSenseConfPath, []byte(content), 0o644)
+}
+
+// Uninstall removes ctrld's drop-in config.
+func (b *PfSenseBackend) Uninstall() error {
+	if err := os.Remove(pfSenseConfPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Reload restarts the dnsmasq service so it re-reads pfSenseConfDir.
+func (b *PfSenseBackend) Reload() error {
+	return exec.Command("service", "dnsmasq", "restart").Run()
+}
@@ -0,0 +1,17 @@
+package dnsmasq
+
+import "testing"
+
+func TestBackendsHaveUniqueNames(t *testing.T) {
+	seen := make(map[string]bool, len(Backends))
+	for _, b := range Backends {
+		name := b.Name()
+		if name == "" {
+			t.Fatalf("backend %T has an empty Name()", b)
+		}
+		if seen[name] {
+			t.Fatalf("duplicate backend name %q", name)
+		}
+		seen[name] = true
+	}
+}
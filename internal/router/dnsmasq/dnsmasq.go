@@ -5,6 +5,8 @@ import (
 	"html/template"
 	"net"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Control-D-Inc/ctrld"
@@ -14,11 +16,39 @@ const CtrldMarker = `# GENERATED BY ctrld - DO NOT MODIFY`
 
 const ConfigContentTmpl = `# GENERATED BY ctrld - DO NOT MODIFY
 no-resolv
+{{- range .ListenInterfaces}}
+interface={{ . }}
+{{- end}}
+{{- if .ListenInterfaces}}
+bind-dynamic
+{{- end}}
+{{- range .DomainRoutes}}
+server=/{{ .Domain }}/{{ .IP }}#{{ .Port }}
+{{- end}}
 {{- range .Upstreams}}
-server={{ .IP }}#{{ .Port }}
+{{- if .Interface}}
+listen-address={{ .IP }}
+{{- end}}
+server={{ .IP }}#{{ .Port }}{{if .Interface}}@{{ .Interface }}{{end}}
 {{- end}}
+{{- if .AddnHosts}}
+addn-hosts={{ .AddnHosts }}
+{{- end}}
+{{- range .Rewrites}}
+address=/{{ .Name }}/{{ .IP }}
+{{- end}}
+{{- if .AddMAC}}
 add-mac
-add-subnet=32,128
+{{- end}}
+{{- if .AddSubnet}}
+add-subnet={{ .AddSubnet }}
+{{- end}}
+{{- if .DNSSEC}}
+dnssec
+{{- range .TrustAnchors}}
+trust-anchor={{ . }}
+{{- end}}
+{{- end}}
 {{- if .CacheDisabled}}
 cache-size=0
 {{- else}}
@@ -41,15 +71,52 @@ if [ -n "$pid" ] && [ -f "/proc/${pid}/cmdline" ]; then
   pc_append "no-resolv" "$config_file"              # do not read /etc/resolv.conf
   # use ctrld as upstream
   pc_delete "server=" "$config_file"
+  {{- if .ListenInterfaces}}
+  pc_delete "interface=" "$config_file"
+  {{- end}}
+  {{- range .ListenInterfaces}}
+  pc_append "interface={{ . }}" "$config_file"
+  {{- end}}
+  {{- if .ListenInterfaces}}
+  pc_delete "bind-dynamic" "$config_file"
+  pc_append "bind-dynamic" "$config_file"
+  {{- end}}
+  {{- range .DomainRoutes}}
+  pc_append "server=/{{ .Domain }}/{{ .IP }}#{{ .Port }}" "$config_file"
+  {{- end}}
+  pc_delete "listen-address=" "$config_file"
   {{- range .Upstreams}}
-  pc_append "server={{ .IP }}#{{ .Port }}" "$config_file"
+  {{- if .Interface}}
+  pc_append "listen-address={{ .IP }}" "$config_file"
+  {{- end}}
+  pc_append "server={{ .IP }}#{{ .Port }}{{if .Interface}}@{{ .Interface }}{{end}}" "$config_file"
+  {{- end}}
+  {{- if .AddnHosts}}
+  pc_delete "addn-hosts=" "$config_file"
+  pc_append "addn-hosts={{ .AddnHosts }}" "$config_file"
+  {{- end}}
+  {{- range .Rewrites}}
+  pc_append "address=/{{ .Name }}/{{ .IP }}" "$config_file"
   {{- end}}
   pc_delete "add-mac" "$config_file"
-  pc_delete "add-subnet" "$config_file"
+  {{- if .AddMAC}}
   pc_append "add-mac" "$config_file"                # add client mac
-  pc_append "add-subnet=32,128" "$config_file"      # add client ip
+  {{- end}}
+  pc_delete "add-subnet" "$config_file"
+  {{- if .AddSubnet}}
+  pc_append "add-subnet={{ .AddSubnet }}" "$config_file"      # add client ip
+  {{- end}}
+  {{- if .DNSSEC}}
+  pc_delete "dnssec" "$config_file"
+  pc_append "dnssec" "$config_file"                 # enable DNSSEC validation
+  pc_delete "trust-anchor=" "$config_file"
+  {{- range .TrustAnchors}}
+  pc_append "trust-anchor={{ . }}" "$config_file"
+  {{- end}}
+  {{- else}}
   pc_delete "dnssec" "$config_file"                 # disable DNSSEC
   pc_delete "trust-anchor=" "$config_file"          # disable DNSSEC
+  {{- end}}
   pc_delete "cache-size=" "$config_file"
   pc_append "cache-size=0" "$config_file"           # disable cache
 	
@@ -67,6 +134,56 @@ fi
 type Upstream struct {
 	IP   string
 	Port int
+	// Interface, if set, scopes this upstream to queries arriving on that
+	// interface, rendered as dnsmasq's "server=IP#port@interface" syntax.
+	Interface string
+}
+
+// DomainRoute represents a dnsmasq domain-specific "server=/domain/IP#port" directive,
+// used to send queries for Domain to a specific upstream instead of the default one.
+// An empty Domain renders as "server=//IP#port", which dnsmasq uses for unqualified names.
+type DomainRoute struct {
+	Domain string
+	IP     string
+	Port   int
+}
+
+// Options controls dnsmasq directives that are safe defaults for most
+// deployments but need to be opt-in/opt-out for some: local DNSSEC
+// validation and EDNS Client Subnet / client MAC pass-through to upstreams.
+type Options struct {
+	// DNSSEC, left false, strips "dnssec"/"trust-anchor=" so dnsmasq never
+	// validates signatures itself. Set true to keep local validation, e.g.
+	// when the upstream is a plain resolver or ctrld chains in front of one.
+	DNSSEC bool
+	// TrustAnchors are additional "trust-anchor=..." lines rendered when DNSSEC is true.
+	TrustAnchors []string
+	// AddSubnet is the add-subnet= value to render, e.g. "32,128". Empty disables ECS.
+	AddSubnet string
+	// AddMAC controls whether add-mac is rendered, leaking the client's MAC to upstreams.
+	AddMAC bool
+}
+
+// DefaultOptions returns ctrld's historical dnsmasq behavior: DNSSEC
+// stripped, full add-subnet ECS, and add-mac enabled.
+func DefaultOptions() Options {
+	return Options{AddSubnet: "32,128", AddMAC: true}
+}
+
+// optionsFromConfig derives Options from cfg's first listener and default
+// upstream, falling back to DefaultOptions' values for anything cfg doesn't
+// configure, so existing configs keep ctrld's historical rendered output.
+func optionsFromConfig(cfg *ctrld.Config) Options {
+	opts := DefaultOptions()
+	if lc := cfg.FirstListener(); lc != nil {
+		opts.DNSSEC = lc.DNSSEC
+		opts.TrustAnchors = lc.TrustAnchors
+	}
+	if uc, ok := cfg.Upstream["0"]; ok && uc.SendClientInfo != nil && !*uc.SendClientInfo {
+		opts.AddSubnet = ""
+		opts.AddMAC = false
+	}
+	return opts
 }
 
 // ConfTmpl generates dnsmasq configuration from ctrld config.
@@ -81,6 +198,12 @@ func ConfTmpl(tmplText string, cfg *ctrld.Config) (string, error) {
 // after ctrld started (like EdgeOS/Ubios, Firewalla ...), dnsmasq cache should not be disabled because
 // the cache-size=0 generated by ctrld will conflict with router's generated config.
 func ConfTmplWithCacheDisabled(tmplText string, cfg *ctrld.Config, cacheDisabled bool) (string, error) {
+	return ConfTmplWithOptions(tmplText, cfg, cacheDisabled, optionsFromConfig(cfg))
+}
+
+// ConfTmplWithOptions is like ConfTmplWithCacheDisabled, but the caller can
+// also control DNSSEC validation and ECS/MAC pass-through via opts.
+func ConfTmplWithOptions(tmplText string, cfg *ctrld.Config, cacheDisabled bool, opts Options) (string, error) {
 	listener := cfg.FirstListener()
 	if listener == nil {
 		return "", errors.New("missing listener")
@@ -90,27 +213,56 @@ func ConfTmplWithCacheDisabled(tmplText string, cfg *ctrld.Config, cacheDisabled
 		ip = "127.0.0.1"
 	}
 	upstreams := []Upstream{{IP: ip, Port: listener.Port}}
-	return confTmpl(tmplText, upstreams, cacheDisabled)
+	addnHosts, rewrites, err := addnHostsAndRewrites(cfg)
+	if err != nil {
+		return "", err
+	}
+	return confTmpl(tmplText, upstreams, domainRoutes(cfg, ip), addnHosts, rewrites, nil, cacheDisabled, opts)
 }
 
 // FirewallaConfTmpl generates dnsmasq config for Firewalla routers.
 func FirewallaConfTmpl(tmplText string, cfg *ctrld.Config) (string, error) {
 	// If ctrld listen on all interfaces, generating config for all of them.
 	if lc := cfg.FirstListener(); lc != nil && (lc.IP == "0.0.0.0" || lc.IP == "") {
-		return confTmpl(tmplText, firewallaUpstreams(lc.Port), false)
+		ifaces := FirewallaSelfInterfaces()
+		listenIfaces := make([]string, 0, len(ifaces))
+		for _, netIface := range ifaces {
+			listenIfaces = append(listenIfaces, netIface.Name)
+		}
+		addnHosts, rewrites, err := addnHostsAndRewrites(cfg)
+		if err != nil {
+			return "", err
+		}
+		return confTmpl(tmplText, firewallaUpstreams(lc.Port), nil, addnHosts, rewrites, listenIfaces, false, optionsFromConfig(cfg))
 	}
 	// Otherwise, generating config for the specific listener from ctrld's config.
 	return ConfTmplWithCacheDisabled(tmplText, cfg, false)
 }
 
-func confTmpl(tmplText string, upstreams []Upstream, cacheDisabled bool) (string, error) {
+func confTmpl(tmplText string, upstreams []Upstream, routes []DomainRoute, addnHosts string, rewrites []HostEntry, listenInterfaces []string, cacheDisabled bool, opts Options) (string, error) {
 	tmpl := template.Must(template.New("").Parse(tmplText))
 	var to = &struct {
-		Upstreams     []Upstream
-		CacheDisabled bool
+		Upstreams        []Upstream
+		DomainRoutes     []DomainRoute
+		AddnHosts        string
+		Rewrites         []HostEntry
+		ListenInterfaces []string
+		CacheDisabled    bool
+		DNSSEC           bool
+		TrustAnchors     []string
+		AddSubnet        string
+		AddMAC           bool
 	}{
-		Upstreams:     upstreams,
-		CacheDisabled: cacheDisabled,
+		Upstreams:        upstreams,
+		DomainRoutes:     routes,
+		AddnHosts:        addnHosts,
+		Rewrites:         rewrites,
+		ListenInterfaces: listenInterfaces,
+		CacheDisabled:    cacheDisabled,
+		DNSSEC:           opts.DNSSEC,
+		TrustAnchors:     opts.TrustAnchors,
+		AddSubnet:        opts.AddSubnet,
+		AddMAC:           opts.AddMAC,
 	}
 	var sb strings.Builder
 	if err := tmpl.Execute(&sb, to); err != nil {
@@ -119,6 +271,64 @@ func confTmpl(tmplText string, upstreams []Upstream, cacheDisabled bool) (string
 	return sb.String(), nil
 }
 
+// domainRoutes walks cfg's policy rules and expands each rule's domains into
+// per-upstream DomainRoute entries, so dnsmasq can send them to their target
+// upstream listener instead of ctrld's default one. defaultIP is the IP of the
+// listener that rules without a resolvable upstream endpoint fall back to.
+func domainRoutes(cfg *ctrld.Config, defaultIP string) []DomainRoute {
+	listenerNames := make([]string, 0, len(cfg.Listener))
+	for name := range cfg.Listener {
+		listenerNames = append(listenerNames, name)
+	}
+	sort.Strings(listenerNames)
+
+	var routes []DomainRoute
+	for _, name := range listenerNames {
+		lc := cfg.Listener[name]
+		if lc.Policy == nil {
+			continue
+		}
+		for _, rule := range lc.Policy.Rules {
+			domains := make([]string, 0, len(rule))
+			for domain := range rule {
+				domains = append(domains, domain)
+			}
+			sort.Strings(domains)
+			for _, domain := range domains {
+				upstreamNames := rule[domain]
+				if len(upstreamNames) == 0 {
+					continue
+				}
+				uc, ok := cfg.Upstream[strings.TrimPrefix(upstreamNames[0], "upstream.")]
+				if !ok {
+					continue
+				}
+				ip, port := upstreamIPPort(uc)
+				if ip == "" {
+					ip = defaultIP
+				}
+				routes = append(routes, DomainRoute{Domain: strings.TrimSuffix(domain, "."), IP: ip, Port: port})
+			}
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Domain < routes[j].Domain })
+	return routes
+}
+
+// upstreamIPPort extracts the host and port dnsmasq should forward to for uc,
+// defaulting to port 53 when uc's endpoint does not specify one.
+func upstreamIPPort(uc *ctrld.UpstreamConfig) (string, int) {
+	host, port, err := net.SplitHostPort(uc.Endpoint)
+	if err != nil {
+		return uc.Endpoint, 53
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		p = 53
+	}
+	return host, p
+}
+
 func firewallaUpstreams(port int) []Upstream {
 	ifaces := FirewallaSelfInterfaces()
 	upstreams := make([]Upstream, 0, len(ifaces))
@@ -127,8 +337,9 @@ func firewallaUpstreams(port int) []Upstream {
 		for _, addr := range addrs {
 			if netIP, ok := addr.(*net.IPNet); ok && netIP.IP.To4() != nil {
 				upstreams = append(upstreams, Upstream{
-					IP:   netIP.IP.To4().String(),
-					Port: port,
+					IP:        netIP.IP.To4().String(),
+					Port:      port,
+					Interface: netIface.Name,
 				})
 			}
 		}
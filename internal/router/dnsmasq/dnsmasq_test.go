@@ -0,0 +1,139 @@
+package dnsmasq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+func TestDomainRoutesDeterministic(t *testing.T) {
+	cfg := &ctrld.Config{
+		Listener: map[string]*ctrld.ListenerConfig{
+			"0": {IP: "127.0.0.1", Port: 5354, Policy: &ctrld.PolicyConfig{
+				Rules: []ctrld.Rule{
+					{
+						"a.com": {"upstream.0"},
+						"b.com": {"upstream.1"},
+						"c.com": {"upstream.0"},
+						"d.com": {"upstream.1"},
+						"e.com": {"upstream.0"},
+						"f.com": {"upstream.1"},
+					},
+				},
+			}},
+		},
+		Upstream: map[string]*ctrld.UpstreamConfig{
+			"0": {Endpoint: "10.0.0.1:53"},
+			"1": {Endpoint: "10.0.0.2:53"},
+		},
+	}
+
+	first := domainRoutes(cfg, "127.0.0.1")
+	for i := 0; i < 20; i++ {
+		next := domainRoutes(cfg, "127.0.0.1")
+		if len(next) != len(first) {
+			t.Fatalf("iteration %d: length changed, got %d want %d", i, len(next), len(first))
+		}
+		for j := range first {
+			if first[j] != next[j] {
+				t.Fatalf("iteration %d: order changed at index %d:\nfirst=%v\nnext=%v", i, j, first, next)
+			}
+		}
+	}
+}
+
+func TestOptionsFromConfig(t *testing.T) {
+	cfg := &ctrld.Config{
+		Listener: map[string]*ctrld.ListenerConfig{
+			"0": {IP: "127.0.0.1", Port: 5354, DNSSEC: true, TrustAnchors: []string{". IN DS 1 2 3 abcd"}},
+		},
+	}
+	opts := optionsFromConfig(cfg)
+	if !opts.DNSSEC {
+		t.Fatalf("expected DNSSEC true from listener config")
+	}
+	if len(opts.TrustAnchors) != 1 || opts.TrustAnchors[0] != ". IN DS 1 2 3 abcd" {
+		t.Fatalf("unexpected TrustAnchors: %v", opts.TrustAnchors)
+	}
+	// Unconfigured AddSubnet/AddMAC fall back to ctrld's historical defaults.
+	if opts.AddSubnet != "32,128" || !opts.AddMAC {
+		t.Fatalf("expected default ECS/MAC behavior, got %+v", opts)
+	}
+}
+
+func TestOptionsFromConfig_SendClientInfoDisabled(t *testing.T) {
+	disabled := false
+	cfg := &ctrld.Config{
+		Listener: map[string]*ctrld.ListenerConfig{
+			"0": {IP: "127.0.0.1", Port: 5354},
+		},
+		Upstream: map[string]*ctrld.UpstreamConfig{
+			"0": {Endpoint: "10.0.0.1:53", SendClientInfo: &disabled},
+		},
+	}
+	opts := optionsFromConfig(cfg)
+	if opts.AddSubnet != "" || opts.AddMAC {
+		t.Fatalf("expected ECS/MAC stripped when SendClientInfo is false, got %+v", opts)
+	}
+}
+
+func TestConfTmplWithOptions_NoRewritesOmitsAddnHosts(t *testing.T) {
+	cfg := testConfig()
+	rendered, err := ConfTmplWithOptions(ConfigContentTmpl, cfg, true, DefaultOptions())
+	if err != nil {
+		t.Fatalf("ConfTmplWithOptions: %v", err)
+	}
+	if strings.Contains(rendered, "addn-hosts=") {
+		t.Fatalf("rendered config references addn-hosts with no configured rewrites:\n%s", rendered)
+	}
+}
+
+func TestConfTmplWithOptions_RewritesWriteHostsFile(t *testing.T) {
+	dir := t.TempDir()
+	orig := ctrldHostsDir
+	ctrldHostsDir = dir
+	t.Cleanup(func() { ctrldHostsDir = orig })
+
+	cfg := testConfig()
+	cfg.Hosts = map[string]string{"nas.lan": "192.168.1.10"}
+
+	rendered, err := ConfTmplWithOptions(ConfigContentTmpl, cfg, true, DefaultOptions())
+	if err != nil {
+		t.Fatalf("ConfTmplWithOptions: %v", err)
+	}
+	if !strings.Contains(rendered, "addn-hosts="+filepath.Join(dir, hostsFileName)) {
+		t.Fatalf("rendered config missing addn-hosts= for configured rewrite:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "address=/nas.lan/192.168.1.10") {
+		t.Fatalf("rendered config missing address= rewrite:\n%s", rendered)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, hostsFileName))
+	if err != nil {
+		t.Fatalf("expected WriteHostsFile to have written the hosts file: %v", err)
+	}
+	if !strings.Contains(string(data), "192.168.1.10 nas.lan") {
+		t.Fatalf("unexpected hosts file content: %q", data)
+	}
+}
+
+func TestConfTmpl_NoExceptInterfaceDirective(t *testing.T) {
+	cfg := testConfig()
+	rendered, err := ConfTmpl(ConfigContentTmpl, cfg)
+	if err != nil {
+		t.Fatalf("ConfTmpl: %v", err)
+	}
+	if strings.Contains(rendered, "except-interface") {
+		t.Fatalf("rendered config references except-interface, which has no producer:\n%s", rendered)
+	}
+
+	rendered, err = ConfTmpl(MerlinPostConfTmpl, cfg)
+	if err != nil {
+		t.Fatalf("ConfTmpl(MerlinPostConfTmpl): %v", err)
+	}
+	if strings.Contains(rendered, "except-interface") {
+		t.Fatalf("rendered postconf script references except-interface, which has no producer:\n%s", rendered)
+	}
+}
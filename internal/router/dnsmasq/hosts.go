@@ -0,0 +1,97 @@
+package dnsmasq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// ctrldHostsDir is the ctrld-owned directory holding addn-hosts zone files.
+// A var, not a const, so tests can point it at a temp directory.
+var ctrldHostsDir = "/etc/ctrld/dnsmasq"
+
+// hostsFileName is the default addn-hosts file name within ctrldHostsDir.
+const hostsFileName = "ctrld.hosts"
+
+// pidFileName is the default location dnsmasq writes its pid to. ReloadDnsmasq
+// does not read it; callers pass the pid file path explicitly.
+const pidFileName = "/var/run/dnsmasq.pid"
+
+// HostEntry is a single local DNS rewrite: Name resolves to IP, either via an
+// addn-hosts zone file written by WriteHostsFile or an address=/name/IP directive.
+type HostEntry struct {
+	Name string
+	IP   string
+}
+
+// hostsFilePath returns the default addn-hosts file path written by WriteHostsFile.
+func hostsFilePath() string {
+	return filepath.Join(ctrldHostsDir, hostsFileName)
+}
+
+// rewritesFromConfig reads cfg's local DNS rewrites (container names, LAN
+// hostnames, blocked domains pinned to 0.0.0.0, ...) into HostEntry values,
+// sorted by Name so callers get a deterministic order out of cfg.Hosts, a map.
+func rewritesFromConfig(cfg *ctrld.Config) []HostEntry {
+	if len(cfg.Hosts) == 0 {
+		return nil
+	}
+	entries := make([]HostEntry, 0, len(cfg.Hosts))
+	for name, ip := range cfg.Hosts {
+		entries = append(entries, HostEntry{Name: name, IP: ip})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// addnHostsAndRewrites derives the addn-hosts file path and HostEntry list to
+// render for cfg, writing the addn-hosts file when cfg defines any rewrites.
+// It returns an empty path and nil entries when cfg has none, so callers
+// don't render a dangling addn-hosts= reference to a file nobody wrote.
+func addnHostsAndRewrites(cfg *ctrld.Config) (string, []HostEntry, error) {
+	rewrites := rewritesFromConfig(cfg)
+	if len(rewrites) == 0 {
+		return "", nil, nil
+	}
+	path := hostsFilePath()
+	if err := WriteHostsFile(path, rewrites); err != nil {
+		return "", nil, err
+	}
+	return path, rewrites, nil
+}
+
+// WriteHostsFile writes entries to path in dnsmasq's hosts-file format
+// ("IP name"), suitable for dnsmasq's addn-hosts directive. One file is
+// expected per zone, so callers managing multiple zones should use distinct
+// paths. The file is written atomically via a temp file + rename so dnsmasq
+// never observes a partial write.
+func WriteHostsFile(path string, entries []HostEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%s %s\n", e.IP, e.Name)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ctrld-hosts-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(sb.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
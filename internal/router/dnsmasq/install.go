@@ -0,0 +1,198 @@
+package dnsmasq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+// ctrldBlockEndMarker closes the block opened by CtrldMarker, so
+// splitManagedBlock can find the block's exact extent instead of guessing
+// from blank lines, which real dnsmasq.conf files don't reliably have
+// between ctrld's block and whatever directives follow it.
+const ctrldBlockEndMarker = "# END GENERATED BY ctrld"
+
+// conflictingKeys are bare directives ctrld's generated block also sets;
+// having them elsewhere in dnsmasq.conf causes dnsmasq to apply both, which
+// is confusing at best and contradictory at worst. "server=" is handled
+// separately, since its domain-scoped form ("server=/domain/ip#port") is
+// meant to coexist with ctrld's block.
+var conflictingKeys = []string{"cache-size=", "no-resolv", "dnssec"}
+
+// directive is a single parsed dnsmasq.conf line, tokenized using dnsmasq's
+// "key", "key=value" and "key=/domain/value" grammar.
+type directive struct {
+	raw string
+	key string
+}
+
+// parseDirectives tokenizes content into one directive per non-blank,
+// non-comment line.
+func parseDirectives(content string) []directive {
+	var out []directive
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key := trimmed
+		if i := strings.IndexByte(trimmed, '='); i != -1 {
+			key = trimmed[:i+1]
+		}
+		out = append(out, directive{raw: line, key: key})
+	}
+	return out
+}
+
+// ConflictError reports dnsmasq.conf directives outside ctrld's managed
+// block that duplicate something ctrld's block already sets. InstallConfig
+// still installs its block when this is returned; it's a warning, not a
+// fatal error, since the pre-existing directives may be intentional.
+type ConflictError struct {
+	Lines []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("dnsmasq: conflicting directives found outside ctrld's managed block: %s", strings.Join(e.Lines, "; "))
+}
+
+// splitManagedBlock locates ctrld's block, delimited by CtrldMarker and
+// ctrldBlockEndMarker, in content and returns the content before it, whether
+// a block was found, and the content following it. A block without an end
+// marker (e.g. installed before ctrldBlockEndMarker existed) is treated as
+// running to the end of the file, since there's no reliable way to tell
+// where it ends.
+func splitManagedBlock(content string) (before string, found bool, after string) {
+	startIdx := strings.Index(content, CtrldMarker)
+	if startIdx == -1 {
+		return content, false, ""
+	}
+	before = content[:startIdx]
+	rest := content[startIdx:]
+	endIdx := strings.Index(rest, ctrldBlockEndMarker)
+	if endIdx == -1 {
+		return before, true, ""
+	}
+	after = rest[endIdx+len(ctrldBlockEndMarker):]
+	after = strings.TrimPrefix(after, "\n")
+	return before, true, after
+}
+
+// isBareServerDirective reports whether raw is a generic
+// "server=IP#port"-style directive, as opposed to a domain-scoped
+// "server=/domain/IP#port" one. Only the generic form conflicts with
+// ctrld's block, which also sets a generic default server=.
+func isBareServerDirective(d directive) bool {
+	if d.key != "server=" {
+		return false
+	}
+	value := strings.TrimPrefix(strings.TrimSpace(d.raw), "server=")
+	return !strings.HasPrefix(value, "/")
+}
+
+// conflicts returns the directives in content that collide with what
+// ctrld's managed block sets.
+func conflicts(content string) []string {
+	var lines []string
+	for _, d := range parseDirectives(content) {
+		if isBareServerDirective(d) {
+			lines = append(lines, d.raw)
+			continue
+		}
+		for _, k := range conflictingKeys {
+			if d.key == k {
+				lines = append(lines, d.raw)
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// InstallConfig renders cfg and installs it into path's ctrld-managed block,
+// replacing any block left by a previous install while leaving everything
+// else in path untouched. The write is atomic (temp file + rename + fsync)
+// so dnsmasq never observes a partial config. If directives outside the
+// block would conflict with ctrld's (cache-size, server=, no-resolv,
+// dnssec), the config is still installed and a *ConflictError is returned.
+func InstallConfig(path string, cfg *ctrld.Config) error {
+	rendered, err := ConfTmpl(ConfigContentTmpl, cfg)
+	if err != nil {
+		return err
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	before, _, after := splitManagedBlock(string(existing))
+
+	var sb strings.Builder
+	sb.WriteString(before)
+	sb.WriteString(rendered)
+	if !strings.HasSuffix(rendered, "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(ctrldBlockEndMarker)
+	sb.WriteString("\n")
+	sb.WriteString(after)
+
+	if err := atomicWriteFile(path, sb.String()); err != nil {
+		return err
+	}
+
+	if lines := conflicts(before + after); len(lines) > 0 {
+		return &ConflictError{Lines: lines}
+	}
+	return nil
+}
+
+// UninstallConfig strips ctrld's managed block from path, leaving everything
+// the user or firmware put before/after it, such as their own
+// "server=/lan/..." entries, untouched. It is a no-op if path doesn't exist
+// or has no ctrld block.
+func UninstallConfig(path string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	before, found, after := splitManagedBlock(string(existing))
+	if !found {
+		return nil
+	}
+	return atomicWriteFile(path, before+after)
+}
+
+// atomicWriteFile writes content to path via a temp file in the same
+// directory, fsync, and rename, so a crash mid-write never leaves path
+// truncated or half-updated.
+func atomicWriteFile(path, content string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".ctrld-conf-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,202 @@
+package dnsmasq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Control-D-Inc/ctrld"
+)
+
+func testConfig() *ctrld.Config {
+	return &ctrld.Config{
+		Listener: map[string]*ctrld.ListenerConfig{
+			"0": {IP: "127.0.0.1", Port: 5354},
+		},
+	}
+}
+
+func TestInstallConfig_ReinstallIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.conf")
+	cfg := testConfig()
+
+	if err := InstallConfig(path, cfg); err != nil {
+		t.Fatalf("first InstallConfig: %v", err)
+	}
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read after first install: %v", err)
+	}
+
+	if err := InstallConfig(path, cfg); err != nil {
+		t.Fatalf("second InstallConfig: %v", err)
+	}
+	second, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read after second install: %v", err)
+	}
+
+	if string(first) != string(second) {
+		t.Fatalf("reinstall over itself changed file content:\nfirst:\n%s\nsecond:\n%s", first, second)
+	}
+	if n := strings.Count(string(second), CtrldMarker); n != 1 {
+		t.Fatalf("expected exactly one ctrld block after reinstall, got %d", n)
+	}
+}
+
+func TestInstallConfig_PreservesUserDirectivesAppendedWithoutBlankLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.conf")
+	cfg := testConfig()
+
+	if err := InstallConfig(path, cfg); err != nil {
+		t.Fatalf("InstallConfig: %v", err)
+	}
+
+	// Append a user directive directly below ctrld's block, with no blank
+	// line separator, as real dnsmasq.conf files often look.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	data = append(data, []byte("server=/internal/10.0.0.5\n")...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := InstallConfig(path, cfg); err != nil {
+		if _, ok := err.(*ConflictError); !ok {
+			t.Fatalf("second InstallConfig: %v", err)
+		}
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read after second install: %v", err)
+	}
+	if !strings.Contains(string(after), "server=/internal/10.0.0.5") {
+		t.Fatalf("user directive appended without a blank line was dropped:\n%s", after)
+	}
+}
+
+func TestInstallConfig_DomainScopedServerDoesNotConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.conf")
+	cfg := testConfig()
+
+	if err := os.WriteFile(path, []byte("server=/lan/192.168.1.1\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	err := InstallConfig(path, cfg)
+	if err != nil {
+		t.Fatalf("InstallConfig flagged a domain-scoped server= as conflicting: %v", err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(after), "server=/lan/192.168.1.1") {
+		t.Fatalf("user's domain-scoped server= entry did not survive install:\n%s", after)
+	}
+}
+
+func TestInstallConfig_FlagsBareServerConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.conf")
+	cfg := testConfig()
+
+	if err := os.WriteFile(path, []byte("server=8.8.8.8\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	err := InstallConfig(path, cfg)
+	ce, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("expected a *ConflictError for bare server=, got %v", err)
+	}
+	if len(ce.Lines) != 1 || ce.Lines[0] != "server=8.8.8.8" {
+		t.Fatalf("unexpected conflict lines: %v", ce.Lines)
+	}
+}
+
+func TestUninstallConfig_PreservesSurroundingContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.conf")
+	cfg := testConfig()
+
+	const before = "# user preamble\nserver=/lan/192.168.1.1\n"
+	if err := os.WriteFile(path, []byte(before), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := InstallConfig(path, cfg); err != nil {
+		t.Fatalf("InstallConfig: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	data = append(data, []byte("server=/internal/10.0.0.5\n")...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := UninstallConfig(path); err != nil {
+		t.Fatalf("UninstallConfig: %v", err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read after uninstall: %v", err)
+	}
+	if strings.Contains(string(after), CtrldMarker) {
+		t.Fatalf("ctrld block survived uninstall:\n%s", after)
+	}
+	if !strings.Contains(string(after), "server=/lan/192.168.1.1") {
+		t.Fatalf("content before ctrld's block was dropped:\n%s", after)
+	}
+	if !strings.Contains(string(after), "server=/internal/10.0.0.5") {
+		t.Fatalf("content after ctrld's block was dropped:\n%s", after)
+	}
+}
+
+func TestSplitManagedBlock(t *testing.T) {
+	content := strings.Join([]string{
+		"# preamble",
+		CtrldMarker,
+		"no-resolv",
+		"server=127.0.0.1#5354",
+		ctrldBlockEndMarker,
+		"server=/internal/10.0.0.5",
+		"",
+	}, "\n")
+
+	before, found, after := splitManagedBlock(content)
+	if !found {
+		t.Fatalf("expected to find a managed block")
+	}
+	if before != "# preamble\n" {
+		t.Fatalf("unexpected before: %q", before)
+	}
+	if after != "server=/internal/10.0.0.5\n" {
+		t.Fatalf("unexpected after: %q", after)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	content := strings.Join([]string{
+		"server=8.8.8.8",
+		"server=/lan/192.168.1.1",
+		"cache-size=150",
+		"no-resolv",
+	}, "\n")
+
+	got := conflicts(content)
+	want := []string{"server=8.8.8.8", "cache-size=150", "no-resolv"}
+	if len(got) != len(want) {
+		t.Fatalf("conflicts = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("conflicts[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
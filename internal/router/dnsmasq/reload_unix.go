@@ -0,0 +1,25 @@
+//go:build !windows
+
+package dnsmasq
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ReloadDnsmasq sends SIGHUP to the dnsmasq process whose pid is stored in
+// pidFile, so it re-reads its config and addn-hosts files without a full restart.
+func ReloadDnsmasq(pidFile string) error {
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid pid in %s: %w", pidFile, err)
+	}
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package dnsmasq
+
+import "errors"
+
+// ReloadDnsmasq is not supported on Windows, since dnsmasq routers are unix-based.
+func ReloadDnsmasq(pidFile string) error {
+	return errors.New("dnsmasq: ReloadDnsmasq is not supported on windows")
+}